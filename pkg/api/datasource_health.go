@@ -0,0 +1,23 @@
+package api
+
+import (
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// GetDatasourceHealthDetailed returns the current readiness state of a
+// datasource: whether its circuit breaker is open, the last error recorded
+// against it, and when it last answered a query or health check
+// successfully.
+// GET /api/datasources/:id/health/detailed
+func (hs *HTTPServer) GetDatasourceHealthDetailed(c *models.ReqContext) Response {
+	datasourceID := c.ParamsInt64(":id")
+
+	ds, err := hs.DatasourceCache.GetDatasource(datasourceID, c.SignedInUser, c.SkipCache)
+	if err != nil {
+		return handleQueryMetricsError(err)
+	}
+
+	detail := tsdb.HealthDetailFor(ds.Id)
+	return JSON(200, &detail)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// fakeDatasourceCache resolves datasourceID to a *models.DataSource of the
+// given fakeEndpoint type without touching the database, so fan-out tests
+// can run against tsdb.HandleRequest end to end.
+type fakeDatasourceCache struct{}
+
+func (fakeDatasourceCache) GetDatasource(datasourceID int64, user *models.SignedInUser, skipCache bool) (*models.DataSource, error) {
+	return &models.DataSource{Id: datasourceID, Type: "fanout-test", JsonData: simplejson.New()}, nil
+}
+
+// fakeSecretsService is a stand-in for the secrets backend that decrypts a
+// datasource's SecureJsonData, optionally failing every call.
+type fakeSecretsService struct {
+	failsWith error
+}
+
+func (f fakeSecretsService) DecryptJsonData(ctx context.Context, sjd map[string][]byte) (map[string]string, error) {
+	if f.failsWith != nil {
+		return nil, f.failsWith
+	}
+	return map[string]string{}, nil
+}
+
+// fakeFanOutEndpoint is a TsdbQueryEndpoint stub that returns one QueryResult
+// per RefId it's asked for, or failsWith if set. Registered per datasource ID
+// (via the dsInfo.Id passed to the GetTsdbQueryEndpointFn) so a single test
+// can mix a succeeding and a failing datasource under the same plugin type.
+type fakeFanOutEndpoint struct {
+	failsWith error
+}
+
+func (f *fakeFanOutEndpoint) Query(ctx context.Context, ds *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	if f.failsWith != nil {
+		return nil, f.failsWith
+	}
+
+	results := map[string]*tsdb.QueryResult{}
+	for _, q := range query.Queries {
+		results[q.RefId] = &tsdb.QueryResult{RefId: q.RefId}
+	}
+	return &tsdb.Response{Results: results}, nil
+}
+
+func newFanOutReqContext() *models.ReqContext {
+	req := httptest.NewRequest("POST", "/api/ds/query", nil)
+	return &models.ReqContext{
+		Context:      &macaron.Context{Req: macaron.Request{Request: req}},
+		SignedInUser: &models.SignedInUser{},
+	}
+}
+
+func TestFanOutQueryByDatasourceMergesAllDatasources(t *testing.T) {
+	tsdb.RegisterTsdbQueryEndpoint("fanout-test", func(dsInfo *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return &fakeFanOutEndpoint{}, nil
+	})
+
+	hs := &HTTPServer{DatasourceCache: fakeDatasourceCache{}, SecretsService: fakeSecretsService{}}
+	byDatasourceID := map[int64][]*tsdb.Query{
+		1: {{RefId: "A"}},
+		2: {{RefId: "B"}},
+		3: {{RefId: "C"}},
+	}
+
+	resp, err := hs.fanOutQueryByDatasource(newFanOutReqContext(), tsdb.NewTimeRange("now-1h", "now"), dtos.MetricRequest{}, []int64{1, 2, 3}, byDatasourceID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, refID := range []string{"A", "B", "C"} {
+		if _, ok := resp.Results[refID]; !ok {
+			t.Fatalf("expected merged results to contain refId %q, got %v", refID, resp.Results)
+		}
+	}
+}
+
+func TestFanOutQueryByDatasourceAllGroupsFailReturnsErrorResultsNotErr(t *testing.T) {
+	boom := errors.New("datasource unreachable")
+	tsdb.RegisterTsdbQueryEndpoint("fanout-test", func(dsInfo *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return &fakeFanOutEndpoint{failsWith: boom}, nil
+	})
+
+	hs := &HTTPServer{DatasourceCache: fakeDatasourceCache{}, SecretsService: fakeSecretsService{}}
+	byDatasourceID := map[int64][]*tsdb.Query{
+		1: {{RefId: "A"}},
+		2: {{RefId: "B"}},
+	}
+
+	resp, err := hs.fanOutQueryByDatasource(newFanOutReqContext(), tsdb.NewTimeRange("now-1h", "now"), dtos.MetricRequest{}, []int64{1, 2}, byDatasourceID)
+	if err != nil {
+		t.Fatalf("a failing datasource group should be reported per-refId, not as a hard error: %v", err)
+	}
+	for _, refID := range []string{"A", "B"} {
+		res, ok := resp.Results[refID]
+		if !ok || res.Error == nil {
+			t.Fatalf("expected refId %q to carry the datasource failure, got %v", refID, resp.Results)
+		}
+	}
+}
+
+func TestFanOutQueryByDatasourceOneFailureDoesNotDiscardTheOthers(t *testing.T) {
+	boom := errors.New("datasource unreachable")
+	tsdb.RegisterTsdbQueryEndpoint("fanout-test", func(dsInfo *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		if dsInfo.Id == 2 {
+			return &fakeFanOutEndpoint{failsWith: boom}, nil
+		}
+		return &fakeFanOutEndpoint{}, nil
+	})
+
+	hs := &HTTPServer{DatasourceCache: fakeDatasourceCache{}, SecretsService: fakeSecretsService{}}
+	byDatasourceID := map[int64][]*tsdb.Query{
+		1: {{RefId: "A"}},
+		2: {{RefId: "B"}},
+	}
+
+	resp, err := hs.fanOutQueryByDatasource(newFanOutReqContext(), tsdb.NewTimeRange("now-1h", "now"), dtos.MetricRequest{}, []int64{1, 2}, byDatasourceID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resA, ok := resp.Results["A"]
+	if !ok || resA.Error != nil {
+		t.Fatalf("expected the healthy datasource's result for refId A to survive the other datasource's failure, got %v", resp.Results)
+	}
+	resB, ok := resp.Results["B"]
+	if !ok || resB.Error == nil {
+		t.Fatalf("expected refId B to carry the failing datasource's error, got %v", resp.Results)
+	}
+}
+
+func TestFanOutQueryByDatasourceWrapsSecretsDecryptionFailure(t *testing.T) {
+	tsdb.RegisterTsdbQueryEndpoint("fanout-test", func(dsInfo *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return &fakeFanOutEndpoint{}, nil
+	})
+
+	hs := &HTTPServer{
+		DatasourceCache: fakeDatasourceCache{},
+		SecretsService:  fakeSecretsService{failsWith: errors.New("vault: connection refused")},
+	}
+	byDatasourceID := map[int64][]*tsdb.Query{1: {{RefId: "A"}}}
+
+	resp, err := hs.fanOutQueryByDatasource(newFanOutReqContext(), tsdb.NewTimeRange("now-1h", "now"), dtos.MetricRequest{}, []int64{1}, byDatasourceID)
+	if err != nil {
+		t.Fatalf("a secrets-decryption failure should be reported per-refId, not as a hard error: %v", err)
+	}
+
+	res, ok := resp.Results["A"]
+	if !ok || res.Error == nil {
+		t.Fatalf("expected refId A to carry the secrets-decryption failure, got %v", resp.Results)
+	}
+	var secretsErr ErrDatasourceSecretsPluginUserFriendly
+	if !errors.As(res.Error, &secretsErr) {
+		t.Fatalf("expected the merged error to be an ErrDatasourceSecretsPluginUserFriendly, got %v", res.Error)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
 	"sort"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"github.com/grafana/grafana/pkg/expr"
 	"github.com/grafana/grafana/pkg/models"
 
@@ -27,19 +31,28 @@ func (hs *HTTPServer) QueryMetricsV2(c *models.ReqContext, reqDTO dtos.MetricReq
 
 	start := time.Now()
 
-	request := &tsdb.TsdbQuery{
-		TimeRange: tsdb.NewTimeRange(reqDTO.From, reqDTO.To),
-		Debug:     reqDTO.Debug,
-		User:      c.SignedInUser,
-	}
+	timeRange := tsdb.NewTimeRange(reqDTO.From, reqDTO.To)
 
 	hasExpr := false
-	var ds *models.DataSource
-	for i, query := range reqDTO.Queries {
+	byDatasourceID := map[int64][]*tsdb.Query{}
+	var datasourceOrder []int64
+	var exprQueries []*tsdb.Query
+	for _, query := range reqDTO.Queries {
 		hs.log.Debug("Processing metrics query", "query", query)
 		name := query.Get("datasource").MustString("")
+
+		tsdbQuery := &tsdb.Query{
+			RefId:         query.Get("refId").MustString("A"),
+			MaxDataPoints: query.Get("maxDataPoints").MustInt64(100),
+			IntervalMs:    query.Get("intervalMs").MustInt64(1000),
+			QueryType:     query.Get("queryType").MustString(""),
+			Model:         query,
+		}
+
 		if name == expr.DatasourceName {
 			hasExpr = true
+			exprQueries = append(exprQueries, tsdbQuery)
+			continue
 		}
 
 		datasourceID, err := query.Get("datasourceId").Int64()
@@ -48,65 +61,271 @@ func (hs *HTTPServer) QueryMetricsV2(c *models.ReqContext, reqDTO dtos.MetricReq
 			return Error(400, "Query missing data source ID", nil)
 		}
 
-		if i == 0 && !hasExpr {
-			ds, err = hs.DatasourceCache.GetDatasource(datasourceID, c.SignedInUser, c.SkipCache)
-			if err != nil {
-				hs.log.Debug("Encountered error getting data source", "err", err, "id", datasourceID)
-				if errors.Is(err, models.ErrDataSourceAccessDenied) {
-					return Error(403, "Access denied to data source", err)
-				}
-				if errors.Is(err, models.ErrDataSourceNotFound) {
-					return Error(400, "Invalid data source ID", err)
-				}
-				return Error(500, "Unable to load data source metadata", err)
-			}
+		if _, exists := byDatasourceID[datasourceID]; !exists {
+			datasourceOrder = append(datasourceOrder, datasourceID)
 		}
-
-		request.Queries = append(request.Queries, &tsdb.Query{
-			RefId:         query.Get("refId").MustString("A"),
-			MaxDataPoints: query.Get("maxDataPoints").MustInt64(100),
-			IntervalMs:    query.Get("intervalMs").MustInt64(1000),
-			QueryType:     query.Get("queryType").MustString(""),
-			Model:         query,
-			DataSource:    ds,
-		})
+		byDatasourceID[datasourceID] = append(byDatasourceID[datasourceID], tsdbQuery)
 	}
 	spent := time.Since(start)
 	fmt.Printf("\nTime spent pre-processing queries: %d\n\n", spent.Milliseconds())
 	start = time.Now()
 
+	// Expressions need the full result set in memory to evaluate against, so
+	// streaming is only offered on the plain datasource fan-out path.
+	if mode := streamingModeFor(c); mode != "" && !hasExpr {
+		results, cancel := hs.streamFanOutQueryByDatasource(c, timeRange, reqDTO, datasourceOrder, byDatasourceID)
+		return &streamQueryResponse{mode: mode, results: results, cancel: cancel}
+	}
+
 	var resp *tsdb.Response
 	var err error
-	if !hasExpr {
-		resp, err = tsdb.HandleRequest(c.Req.Context(), ds, request)
-		if err != nil {
-			return Error(500, "Metric request error", err)
-		}
-		spent := time.Since(start)
-		fmt.Printf("\nTime spent handling request: %d\n\n", spent.Milliseconds())
-	} else {
+	if hasExpr {
 		if !hs.Cfg.IsExpressionsEnabled() {
 			return Error(404, "Expressions feature toggle is not enabled", nil)
 		}
 
+		// expr.WrapTransformData executes the underlying datasource queries
+		// itself, so they must only be resolved (datasource + headers), not
+		// run, here - running them via fanOutQueryByDatasource as well would
+		// execute every underlying query twice.
+		if err := hs.resolveDatasourceQueriesConcurrently(c, timeRange, reqDTO, datasourceOrder, byDatasourceID); err != nil {
+			return handleQueryMetricsError(err)
+		}
+
+		request := &tsdb.TsdbQuery{TimeRange: timeRange, Debug: reqDTO.Debug, User: c.SignedInUser}
+		for _, datasourceID := range datasourceOrder {
+			request.Queries = append(request.Queries, byDatasourceID[datasourceID]...)
+		}
+		request.Queries = append(request.Queries, exprQueries...)
+
 		resp, err = expr.WrapTransformData(c.Req.Context(), request)
 		if err != nil {
-			return Error(500, "Transform request error", err)
+			return handleQueryMetricsError(err)
+		}
+	} else {
+		resp, err = hs.fanOutQueryByDatasource(c, timeRange, reqDTO, datasourceOrder, byDatasourceID)
+		if err != nil {
+			return handleQueryMetricsError(err)
 		}
 	}
+	spentHandling := time.Since(start)
+	fmt.Printf("\nTime spent handling request: %d\n\n", spentHandling.Milliseconds())
 
-	statusCode := 200
 	for _, res := range resp.Results {
 		if res.Error != nil {
 			res.ErrorString = res.Error.Error()
-			resp.Message = res.ErrorString
-			statusCode = 400
+			res.ErrorCode = errorCodeFor(res.Error)
 		}
 	}
+	statusCode := overallStatusCode(resp.Results)
+	if statusCode >= 400 {
+		resp.Message = "One or more queries failed"
+	}
 
 	return jsonStreaming(statusCode, resp)
 }
 
+// fanOutQueryByDatasource resolves and queries each datasource in
+// byDatasourceID concurrently, bounded by maxConcurrentDatasourceQueries, and
+// merges the results into a single tsdb.Response keyed by RefId. This avoids
+// a slow datasource in a mixed-datasource panel (e.g. Prometheus + Loki)
+// holding up the unrelated queries alongside it.
+//
+// A group that fails to resolve or query does not cancel the other groups
+// or discard their results: it merges a failed QueryResult for each of that
+// group's RefIds instead, the same way streamFanOutQueryByDatasource does,
+// so one bad datasource degrades to a partial response (per chunk0-2's
+// per-refId error handling) rather than poisoning the whole panel.
+func (hs *HTTPServer) fanOutQueryByDatasource(c *models.ReqContext, timeRange *tsdb.TimeRange, reqDTO dtos.MetricRequest, datasourceOrder []int64, byDatasourceID map[int64][]*tsdb.Query) (*tsdb.Response, error) {
+	merged := &tsdb.Response{Results: map[string]*tsdb.QueryResult{}}
+	if len(datasourceOrder) == 0 {
+		return merged, nil
+	}
+
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(c.Req.Context())
+	sem := make(chan struct{}, maxConcurrentDatasourceQueries())
+
+	mergeGroupError := func(queries []*tsdb.Query, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, q := range queries {
+			merged.Results[q.RefId] = &tsdb.QueryResult{RefId: q.RefId, Error: err}
+		}
+	}
+
+	for _, datasourceID := range datasourceOrder {
+		datasourceID := datasourceID
+		queries := byDatasourceID[datasourceID]
+
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			tsdbQuery, err := hs.resolveDatasourceQueries(ctx, c, timeRange, reqDTO, datasourceID, queries)
+			if err != nil {
+				mergeGroupError(queries, err)
+				return nil
+			}
+
+			resp, err := tsdb.HandleRequest(ctx, tsdbQuery.Queries[0].DataSource, tsdbQuery)
+			if err != nil {
+				mergeGroupError(queries, err)
+				return nil
+			}
+
+			mu.Lock()
+			for refID, res := range resp.Results {
+				merged.Results[refID] = res
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	// Every closure above returns nil - per-datasource query failures are
+	// merged into merged.Results instead of propagated here - so g.Wait
+	// only ever blocks until all groups are done.
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// streamFanOutQueryByDatasource is the streaming counterpart of
+// fanOutQueryByDatasource: it sends each datasource's results onto the
+// returned channel as soon as that datasource's query completes, rather than
+// waiting for every datasource to finish before returning anything. The
+// channel is closed once every datasource group has been drained.
+//
+// The returned cancel func must be called by the caller once it stops
+// reading from the channel (e.g. WriteTo hit a write error because the
+// client disconnected) - otherwise a producer goroutine blocked sending a
+// result nobody will ever read again would leak for good.
+func (hs *HTTPServer) streamFanOutQueryByDatasource(c *models.ReqContext, timeRange *tsdb.TimeRange, reqDTO dtos.MetricRequest, datasourceOrder []int64, byDatasourceID map[int64][]*tsdb.Query) (<-chan *tsdb.QueryResult, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Req.Context())
+	out := make(chan *tsdb.QueryResult)
+
+	send := func(res *tsdb.QueryResult) {
+		select {
+		case out <- res:
+		case <-ctx.Done():
+		}
+	}
+
+	sendGroupError := func(queries []*tsdb.Query, err error) {
+		for _, q := range queries {
+			send(&tsdb.QueryResult{RefId: q.RefId, Error: err})
+		}
+	}
+
+	go func() {
+		defer close(out)
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxConcurrentDatasourceQueries())
+
+		for _, datasourceID := range datasourceOrder {
+			datasourceID := datasourceID
+			queries := byDatasourceID[datasourceID]
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				tsdbQuery, err := hs.resolveDatasourceQueries(ctx, c, timeRange, reqDTO, datasourceID, queries)
+				if err != nil {
+					sendGroupError(queries, err)
+					return
+				}
+
+				results, err := tsdb.HandleRequestStream(ctx, tsdbQuery.Queries[0].DataSource, tsdbQuery)
+				if err != nil {
+					sendGroupError(queries, err)
+					return
+				}
+
+				for res := range results {
+					send(res)
+				}
+			}()
+		}
+
+		wg.Wait()
+	}()
+
+	return out, cancel
+}
+
+// maxConcurrentDatasourceQueries bounds the datasource fan-out worker pool.
+func maxConcurrentDatasourceQueries() int {
+	if n := runtime.GOMAXPROCS(0); n > 0 {
+		return n
+	}
+	return 4
+}
+
+// resolveDatasourceQueries looks up datasourceID, attaches it (and its
+// forwarded/OAuth headers and decrypted secure JSON data) to each of
+// queries, and wraps them in a tsdb.TsdbQuery ready to hand to
+// tsdb.HandleRequest or tsdb.HandleRequestStream.
+func (hs *HTTPServer) resolveDatasourceQueries(ctx context.Context, c *models.ReqContext, timeRange *tsdb.TimeRange, reqDTO dtos.MetricRequest, datasourceID int64, queries []*tsdb.Query) (*tsdb.TsdbQuery, error) {
+	ds, err := hs.DatasourceCache.GetDatasource(datasourceID, c.SignedInUser, c.SkipCache)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := hs.queryHeadersFor(ctx, c, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	secureJSONData, err := hs.decryptedSecureJSONDataFor(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range queries {
+		q.DataSource = ds
+		q.Headers = headers
+		q.SecureJsonData = secureJSONData
+	}
+
+	return &tsdb.TsdbQuery{
+		TimeRange: timeRange,
+		Debug:     reqDTO.Debug,
+		User:      c.SignedInUser,
+		Queries:   queries,
+	}, nil
+}
+
+// resolveDatasourceQueriesConcurrently resolves the datasource, headers, and
+// secure JSON data for every group in byDatasourceID, in parallel, without
+// executing any query. It's used ahead of expr.WrapTransformData, which runs
+// the underlying queries itself - fanning them out here as well would
+// execute each one twice.
+func (hs *HTTPServer) resolveDatasourceQueriesConcurrently(c *models.ReqContext, timeRange *tsdb.TimeRange, reqDTO dtos.MetricRequest, datasourceOrder []int64, byDatasourceID map[int64][]*tsdb.Query) error {
+	g, ctx := errgroup.WithContext(c.Req.Context())
+	sem := make(chan struct{}, maxConcurrentDatasourceQueries())
+
+	for _, datasourceID := range datasourceOrder {
+		datasourceID := datasourceID
+		queries := byDatasourceID[datasourceID]
+
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			_, err := hs.resolveDatasourceQueries(ctx, c, timeRange, reqDTO, datasourceID, queries)
+			return err
+		})
+	}
+
+	return g.Wait()
+}
+
 // QueryMetrics returns query metrics
 // POST /api/tsdb/query
 func (hs *HTTPServer) QueryMetrics(c *models.ReqContext, reqDto dtos.MetricRequest) Response {
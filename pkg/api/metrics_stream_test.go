@@ -0,0 +1,24 @@
+package api
+
+import "testing"
+
+func TestCombineStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		current  int
+		next     int
+		expected int
+	}{
+		{"first error sets status", 200, 403, 403},
+		{"same status repeats", 403, 403, 403},
+		{"mismatched statuses fall back to 500", 403, 502, 500},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := combineStatus(tc.current, tc.next); got != tc.expected {
+				t.Fatalf("combineStatus(%d, %d) = %d, want %d", tc.current, tc.next, got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// notReadyRetryAfterSeconds is the Retry-After value returned alongside a
+// 503 for a datasource whose circuit breaker is open.
+const notReadyRetryAfterSeconds = 10
+
+// ErrDatasourceSecretsPluginUserFriendly wraps a secrets-decryption failure
+// with a message safe to show the user, instead of leaking the underlying
+// plugin/storage error. Constructed by decryptedSecureJSONDataFor.
+type ErrDatasourceSecretsPluginUserFriendly struct {
+	Message string
+	Err     error
+}
+
+func (e ErrDatasourceSecretsPluginUserFriendly) Error() string {
+	return e.Message
+}
+
+func (e ErrDatasourceSecretsPluginUserFriendly) Unwrap() error {
+	return e.Err
+}
+
+// errorCodeFor maps an error from the query pipeline to the HTTP status code
+// that best describes it.
+func errorCodeFor(err error) int {
+	if err == nil {
+		return 200
+	}
+
+	var secretsErr ErrDatasourceSecretsPluginUserFriendly
+	var upstreamErr *tsdb.ErrUpstreamFailure
+	var notReadyErr *tsdb.ErrDatasourceNotReady
+	switch {
+	case errors.Is(err, models.ErrDataSourceAccessDenied):
+		return 403
+	case errors.Is(err, models.ErrDataSourceNotFound):
+		return 404
+	case errors.As(err, &notReadyErr):
+		return 503
+	case errors.Is(err, context.DeadlineExceeded):
+		return 504
+	case errors.As(err, &secretsErr):
+		return 400
+	case errors.As(err, &upstreamErr):
+		return 502
+	default:
+		return 500
+	}
+}
+
+// handleQueryMetricsError maps err to the Response with the HTTP status code
+// and message that best describes it, used when the query pipeline fails
+// before any per-refId results are available.
+func handleQueryMetricsError(err error) Response {
+	var secretsErr ErrDatasourceSecretsPluginUserFriendly
+	var upstreamErr *tsdb.ErrUpstreamFailure
+	var notReadyErr *tsdb.ErrDatasourceNotReady
+	switch {
+	case errors.Is(err, models.ErrDataSourceAccessDenied):
+		return Error(403, "Access denied to data source", err)
+	case errors.Is(err, models.ErrDataSourceNotFound):
+		return Error(404, "Invalid data source ID", err)
+	case errors.As(err, &notReadyErr):
+		return Error(503, "Datasource is not ready", err).Header("Retry-After", strconv.Itoa(notReadyRetryAfterSeconds))
+	case errors.Is(err, context.DeadlineExceeded):
+		return Error(504, "Datasource query timed out", err)
+	case errors.As(err, &secretsErr):
+		return Error(400, secretsErr.Message, err)
+	case errors.As(err, &upstreamErr):
+		return Error(502, "Datasource query failed", err)
+	default:
+		return Error(500, "Metric request error", err)
+	}
+}
+
+// overallStatusCode derives the top-level HTTP status for a batch of
+// per-refId results. A mix of successes and failures is reported as 207 so
+// the frontend can render partial success instead of treating the whole
+// response as a single failure.
+func overallStatusCode(results map[string]*tsdb.QueryResult) int {
+	seenSuccess := false
+	code := 0
+
+	for _, res := range results {
+		if res.Error == nil {
+			seenSuccess = true
+			continue
+		}
+		resCode := errorCodeFor(res.Error)
+		switch {
+		case code == 0:
+			code = resCode
+		case code != resCode:
+			code = 500
+		}
+	}
+
+	if code == 0 {
+		return 200
+	}
+	if seenSuccess {
+		return 207
+	}
+	return code
+}
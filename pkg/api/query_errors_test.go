@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+func TestErrorCodeForUpstreamFailure(t *testing.T) {
+	err := &tsdb.ErrUpstreamFailure{DatasourceID: 1, Err: errors.New("connection refused")}
+
+	if code := errorCodeFor(err); code != 502 {
+		t.Fatalf("expected 502 for an upstream datasource failure, got %d", code)
+	}
+}
+
+func TestErrorCodeForNotReady(t *testing.T) {
+	err := &tsdb.ErrDatasourceNotReady{DatasourceID: 1, Reason: errors.New("breaker open")}
+
+	if code := errorCodeFor(err); code != 503 {
+		t.Fatalf("expected 503 for a not-ready datasource, got %d", code)
+	}
+}
+
+func TestErrorCodeForUnknownError(t *testing.T) {
+	if code := errorCodeFor(errors.New("boom")); code != 500 {
+		t.Fatalf("expected 500 for an unrecognized error, got %d", code)
+	}
+}
+
+func TestErrorCodeForSecretsPluginFailure(t *testing.T) {
+	err := ErrDatasourceSecretsPluginUserFriendly{Message: "Failed to decrypt data source credentials", Err: errors.New("vault: connection refused")}
+
+	if code := errorCodeFor(err); code != 400 {
+		t.Fatalf("expected 400 for a secrets-decryption failure, got %d", code)
+	}
+}
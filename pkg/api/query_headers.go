@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// forwardedRequestHeaders is the allow-list of incoming request headers that
+// are safe to pass through to a datasource once forwarding has been opted
+// into via "forwardRequestHeaders" in the datasource's JSON data. Cookie is
+// deliberately excluded: forwarding it would hand the viewing user's Grafana
+// session to whatever URL the datasource is configured to call.
+var forwardedRequestHeaders = []string{
+	"X-Grafana-User",
+	"traceparent",
+	"tracestate",
+}
+
+// queryHeadersFor builds the set of HTTP headers QueryMetricsV2 should
+// attach to every query issued against ds: the allow-listed incoming request
+// headers when ds has "Forward Request Headers" enabled, plus the signed-in
+// user's OAuth token when ds has "Forward OAuth Identity" enabled.
+func (hs *HTTPServer) queryHeadersFor(ctx context.Context, c *models.ReqContext, ds *models.DataSource) (map[string]string, error) {
+	headers := map[string]string{}
+
+	if ds.JsonData.Get("forwardRequestHeaders").MustBool(false) {
+		for _, name := range forwardedRequestHeaders {
+			if value := c.Req.Header.Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+	}
+
+	if !ds.JsonData.Get("oauthPassThru").MustBool(false) {
+		return headers, nil
+	}
+
+	token, err := hs.OAuthTokenService.GetCurrentOAuthToken(ctx, c.SignedInUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OAuth token for forwarding: %w", err)
+	}
+	if token == nil {
+		return headers, nil
+	}
+
+	headers["Authorization"] = fmt.Sprintf("%s %s", token.Type(), token.AccessToken)
+	return headers, nil
+}
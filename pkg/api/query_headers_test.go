@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+func reqContextWithHeaders(headers map[string]string) *models.ReqContext {
+	req := httptest.NewRequest("POST", "/api/ds/query", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return &models.ReqContext{
+		Context:      &macaron.Context{Req: macaron.Request{Request: req}},
+		SignedInUser: &models.SignedInUser{},
+	}
+}
+
+func TestQueryHeadersForDropsHeadersWhenForwardingNotEnabled(t *testing.T) {
+	c := reqContextWithHeaders(map[string]string{
+		"X-Grafana-User": "admin",
+		"Cookie":         "grafana_session=secret",
+		"traceparent":    "00-trace-01",
+	})
+	ds := &models.DataSource{JsonData: simplejson.New()}
+
+	headers, err := (&HTTPServer{}).queryHeadersFor(context.Background(), c, ds)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(headers) != 0 {
+		t.Fatalf("expected no headers forwarded without opt-in, got %v", headers)
+	}
+}
+
+func TestQueryHeadersForNeverForwardsCookie(t *testing.T) {
+	c := reqContextWithHeaders(map[string]string{
+		"X-Grafana-User": "admin",
+		"Cookie":         "grafana_session=secret",
+		"traceparent":    "00-trace-01",
+	})
+	ds := &models.DataSource{JsonData: simplejson.NewFromAny(&util.DynMap{"forwardRequestHeaders": true})}
+
+	headers, err := (&HTTPServer{}).queryHeadersFor(context.Background(), c, ds)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := headers["Cookie"]; ok {
+		t.Fatalf("Cookie must never be forwarded to a datasource, even with forwarding opted in: %v", headers)
+	}
+	if headers["X-Grafana-User"] != "admin" {
+		t.Fatalf("expected X-Grafana-User to be forwarded once opted in, got %v", headers)
+	}
+	if headers["traceparent"] != "00-trace-01" {
+		t.Fatalf("expected traceparent to be forwarded once opted in, got %v", headers)
+	}
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// decryptedSecureJSONDataFor decrypts ds's SecureJsonData through the
+// secrets backend so the plugin adapter querying it never has to. A failure
+// here (e.g. the secrets plugin can't reach its backing store) is wrapped in
+// ErrDatasourceSecretsPluginUserFriendly instead of surfacing whatever raw
+// error the secrets plugin returned.
+func (hs *HTTPServer) decryptedSecureJSONDataFor(ctx context.Context, ds *models.DataSource) (map[string]string, error) {
+	secureJSONData, err := hs.SecretsService.DecryptJsonData(ctx, ds.SecureJsonData)
+	if err != nil {
+		return nil, ErrDatasourceSecretsPluginUserFriendly{
+			Message: "Failed to decrypt data source credentials",
+			Err:     err,
+		}
+	}
+	return secureJSONData, nil
+}
@@ -0,0 +1,47 @@
+package tsdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// RunHealthCheckLoop polls every datasource returned by listDatasources on
+// each tick, calling its plugin's Ready() check (when implemented) to trip
+// or reset that datasource's circuit breaker ahead of the next real query.
+// It blocks until ctx is cancelled.
+func RunHealthCheckLoop(ctx context.Context, interval time.Duration, listDatasources func() ([]*models.DataSource, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkDatasourcesOnce(listDatasources)
+		}
+	}
+}
+
+func checkDatasourcesOnce(listDatasources func() ([]*models.DataSource, error)) {
+	datasources, err := listDatasources()
+	if err != nil {
+		return
+	}
+
+	for _, ds := range datasources {
+		endpoint, err := getTsdbQueryEndpointFor(ds)
+		if err != nil {
+			continue
+		}
+
+		ready, ok := endpoint.(DatasourceReadiness)
+		if !ok {
+			continue
+		}
+
+		breakerFor(ds.Id).recordResult(ready.Ready())
+	}
+}
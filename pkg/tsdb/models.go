@@ -0,0 +1,81 @@
+package tsdb
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// TsdbQuery is the container for the full set of queries sent to HandleRequest,
+// along with the shared time range and the user issuing them.
+type TsdbQuery struct {
+	TimeRange *TimeRange
+	Queries   []*Query
+	Debug     bool
+	User      *models.SignedInUser
+}
+
+// Query represents a single query (RefId) against a single data source.
+type Query struct {
+	RefId         string
+	Model         *simplejson.Json
+	DataSource    *models.DataSource
+	MaxDataPoints int64
+	IntervalMs    int64
+	QueryType     string
+	// Headers are additional HTTP headers (e.g. a forwarded Authorization or
+	// X-Grafana-User) that the plugin adapter should attach to the outgoing
+	// request to the datasource, on top of whatever the datasource's own
+	// configuration already sets.
+	Headers map[string]string
+	// SecureJsonData is DataSource.SecureJsonData decrypted ahead of time by
+	// the API layer, so the plugin adapter never has to call out to the
+	// secrets backend itself.
+	SecureJsonData map[string]string
+}
+
+// TimeRange represents a query time range.
+type TimeRange struct {
+	From string
+	To   string
+	now  time.Time
+}
+
+// NewTimeRange creates a new TimeRange.
+func NewTimeRange(from, to string) *TimeRange {
+	return &TimeRange{From: from, To: to, now: time.Now()}
+}
+
+// Response is the result of a call to HandleRequest, keyed by RefId.
+type Response struct {
+	Results map[string]*QueryResult `json:"results"`
+	Message string                  `json:"message,omitempty"`
+}
+
+// QueryResult holds the result (or error) of a single RefId.
+type QueryResult struct {
+	Error       error  `json:"-"`
+	ErrorString string `json:"error,omitempty"`
+	// ErrorCode is the HTTP status code that best describes Error, set by the
+	// API layer so the frontend can distinguish e.g. an access-denied query
+	// from an upstream timeout within the same partial response.
+	ErrorCode int              `json:"errorCode,omitempty"`
+	RefId     string           `json:"refId"`
+	Meta      *simplejson.Json `json:"meta,omitempty"`
+	Series    TimeSeriesSlice  `json:"series"`
+}
+
+// NewQueryResult creates a new QueryResult.
+func NewQueryResult() *QueryResult {
+	return &QueryResult{Series: make(TimeSeriesSlice, 0)}
+}
+
+// TimeSeriesSlice is a slice of time series points returned by a datasource.
+type TimeSeriesSlice []*TimeSeries
+
+// TimeSeries is a single named series of points.
+type TimeSeries struct {
+	Name   string
+	Points [][2]*float64
+}
@@ -0,0 +1,70 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// TsdbQueryEndpoint is implemented by each datasource plugin adapter.
+type TsdbQueryEndpoint interface {
+	Query(ctx context.Context, ds *models.DataSource, query *TsdbQuery) (*Response, error)
+}
+
+// GetTsdbQueryEndpointFn is registered per datasource type by the plugin it backs.
+type GetTsdbQueryEndpointFn func(dsInfo *models.DataSource) (TsdbQueryEndpoint, error)
+
+var registry = map[string]GetTsdbQueryEndpointFn{}
+
+// RegisterTsdbQueryEndpoint makes a datasource plugin's endpoint resolvable by HandleRequest.
+func RegisterTsdbQueryEndpoint(pluginId string, fn GetTsdbQueryEndpointFn) {
+	registry[pluginId] = fn
+}
+
+func getTsdbQueryEndpointFor(dsInfo *models.DataSource) (TsdbQueryEndpoint, error) {
+	fn, exists := registry[dsInfo.Type]
+	if !exists {
+		return nil, fmt.Errorf("could not find datasource plugin for %q", dsInfo.Type)
+	}
+	return fn(dsInfo)
+}
+
+// HandleRequest resolves the endpoint for dsInfo and executes query against
+// it, short-circuiting with ErrDatasourceNotReady when the datasource's
+// circuit breaker is open or its plugin reports itself not ready, and
+// retrying transient failures according to the datasource's retry policy.
+func HandleRequest(ctx context.Context, dsInfo *models.DataSource, query *TsdbQuery) (*Response, error) {
+	endpoint, err := getTsdbQueryEndpointFor(dsInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	breaker := breakerFor(dsInfo.Id)
+	if !breaker.allow() {
+		_, lastErr, _ := breaker.snapshot()
+		return nil, &ErrDatasourceNotReady{DatasourceID: dsInfo.Id, Reason: lastErr}
+	}
+
+	if ready, ok := endpoint.(DatasourceReadiness); ok {
+		if err := ready.Ready(); err != nil {
+			notReady := &ErrDatasourceNotReady{DatasourceID: dsInfo.Id, Reason: err}
+			breaker.recordResult(notReady)
+			return nil, notReady
+		}
+	}
+
+	policy := retryPolicyFor(dsInfo)
+	var resp *Response
+	if !policy.enabled || !isIdempotent(query) {
+		resp, err = endpoint.Query(ctx, dsInfo, query)
+	} else {
+		resp, err = queryWithRetry(ctx, endpoint, dsInfo, query, policy)
+	}
+
+	breaker.recordResult(err)
+	if err != nil {
+		return nil, &ErrUpstreamFailure{DatasourceID: dsInfo.Id, Err: err}
+	}
+	return resp, nil
+}
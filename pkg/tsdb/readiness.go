@@ -0,0 +1,153 @@
+package tsdb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DatasourceReadiness is optionally implemented by a TsdbQueryEndpoint to
+// report whether it can currently serve queries, e.g. because the plugin
+// process is still starting up or the embedded testdata source is still
+// replaying its WAL.
+type DatasourceReadiness interface {
+	Ready() error
+}
+
+// breakerTripThreshold is the number of consecutive failures (either a
+// failed query or a failed Ready() health check) after which a datasource's
+// circuit breaker opens.
+const breakerTripThreshold = 5
+
+// breakerCooldown is how long an open breaker stays fully closed-off before
+// HandleRequest starts letting a single probe request through again (a
+// "half-open" retry). Without this, a datasource whose plugin doesn't
+// implement DatasourceReadiness - and so is never reset by the background
+// health-check loop - would stay tripped for the life of the process.
+const breakerCooldown = 30 * time.Second
+
+// breakerState is the per-datasource circuit breaker and health state kept
+// by HandleRequest and the background health-check loop.
+type breakerState struct {
+	mu          sync.Mutex
+	open        bool
+	probing     bool
+	failures    int
+	lastError   error
+	lastSuccess time.Time
+	openedAt    time.Time
+}
+
+var breakers sync.Map // map[int64]*breakerState
+
+func breakerFor(datasourceID int64) *breakerState {
+	v, _ := breakers.LoadOrStore(datasourceID, &breakerState{})
+	return v.(*breakerState)
+}
+
+func (b *breakerState) recordResult(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.open = false
+		b.probing = false
+		b.lastSuccess = time.Now()
+		return
+	}
+
+	b.lastError = err
+	b.failures++
+	switch {
+	case b.open:
+		// A failed half-open probe restarts the cooldown rather than
+		// leaving the original openedAt in the past, which would otherwise
+		// let every subsequent request straight through as "half-open".
+		b.openedAt = time.Now()
+		b.probing = false
+	case b.failures >= breakerTripThreshold:
+		b.open = true
+		b.openedAt = time.Now()
+	}
+}
+
+// allow reports whether a request should be let through: the breaker is
+// closed, or it's been open long enough for a half-open probe. Once the
+// cooldown has elapsed, only the first caller to observe it is let through
+// (the probe) - every other concurrent caller keeps getting false until that
+// probe calls recordResult, which either closes the breaker or restarts the
+// cooldown. This avoids a thundering herd of concurrent fan-out queries all
+// hitting a still-down datasource the instant its cooldown expires.
+func (b *breakerState) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if time.Since(b.openedAt) < breakerCooldown {
+		return false
+	}
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breakerState) snapshot() (open bool, lastError error, lastSuccess time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open, b.lastError, b.lastSuccess
+}
+
+// ErrDatasourceNotReady is returned by HandleRequest when the datasource's
+// circuit breaker is open, or its plugin reports Ready() != nil.
+type ErrDatasourceNotReady struct {
+	DatasourceID int64
+	Reason       error
+}
+
+func (e *ErrDatasourceNotReady) Error() string {
+	return fmt.Sprintf("datasource %d is not ready: %s", e.DatasourceID, e.Reason)
+}
+
+func (e *ErrDatasourceNotReady) Unwrap() error {
+	return e.Reason
+}
+
+// ErrUpstreamFailure wraps an error returned by a datasource plugin's Query
+// call itself, distinguishing it from errors in Grafana's own request
+// handling (missing datasource, access denied, breaker open, ...) so the API
+// layer can report it as a 502 rather than a generic 500.
+type ErrUpstreamFailure struct {
+	DatasourceID int64
+	Err          error
+}
+
+func (e *ErrUpstreamFailure) Error() string {
+	return fmt.Sprintf("datasource %d query failed: %s", e.DatasourceID, e.Err)
+}
+
+func (e *ErrUpstreamFailure) Unwrap() error {
+	return e.Err
+}
+
+// HealthDetail is the readiness snapshot served by
+// /api/datasources/:id/health/detailed.
+type HealthDetail struct {
+	Ready       bool      `json:"ready"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastSuccess time.Time `json:"lastSuccess,omitempty"`
+}
+
+// HealthDetailFor returns the current readiness snapshot for a datasource.
+func HealthDetailFor(datasourceID int64) HealthDetail {
+	open, lastErr, lastSuccess := breakerFor(datasourceID).snapshot()
+	detail := HealthDetail{Ready: !open, LastSuccess: lastSuccess}
+	if lastErr != nil {
+		detail.LastError = lastErr.Error()
+	}
+	return detail
+}
@@ -0,0 +1,73 @@
+package tsdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerStateHalfOpenAfterCooldown(t *testing.T) {
+	b := &breakerState{}
+	failure := errors.New("boom")
+
+	for i := 0; i < breakerTripThreshold; i++ {
+		b.recordResult(failure)
+	}
+
+	if b.allow() {
+		t.Fatalf("breaker should not allow requests immediately after tripping")
+	}
+
+	b.openedAt = time.Now().Add(-breakerCooldown)
+	if !b.allow() {
+		t.Fatalf("breaker should allow a half-open probe once the cooldown has elapsed")
+	}
+
+	b.recordResult(nil)
+	if open, _, _ := b.snapshot(); open {
+		t.Fatalf("breaker should close after a successful half-open probe")
+	}
+}
+
+func TestBreakerStateReopensOnFailedProbe(t *testing.T) {
+	b := &breakerState{}
+	failure := errors.New("boom")
+
+	for i := 0; i < breakerTripThreshold; i++ {
+		b.recordResult(failure)
+	}
+	b.openedAt = time.Now().Add(-breakerCooldown)
+
+	b.recordResult(failure)
+	if open, _, _ := b.snapshot(); !open {
+		t.Fatalf("breaker should remain open after a failed half-open probe")
+	}
+	if b.allow() {
+		t.Fatalf("breaker should not allow another probe until the cooldown elapses again")
+	}
+}
+
+func TestBreakerStateOnlyOneProbePerCooldown(t *testing.T) {
+	b := &breakerState{}
+	failure := errors.New("boom")
+
+	for i := 0; i < breakerTripThreshold; i++ {
+		b.recordResult(failure)
+	}
+	b.openedAt = time.Now().Add(-breakerCooldown)
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if b.allow() {
+			allowed++
+		}
+	}
+	if allowed != 1 {
+		t.Fatalf("expected exactly one concurrent caller to be let through as the probe, got %d", allowed)
+	}
+
+	b.recordResult(nil)
+	if !b.allow() {
+		t.Fatalf("breaker should allow requests again once the probe succeeds")
+	}
+}
@@ -0,0 +1,116 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// retryPolicy controls how HandleRequest retries a datasource call after a
+// transient error. It's read off the datasource's JsonData so it can be
+// tuned per-datasource without a code change.
+type retryPolicy struct {
+	enabled    bool
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+const (
+	defaultMaxRetries = 2
+	defaultBaseDelay  = 100 * time.Millisecond
+)
+
+// retryPolicyFor reads jsonData.queryRetries / jsonData.queryRetryBaseDelayMs
+// off the datasource, falling back to the package defaults. A queryRetries
+// of 0 disables retries for that datasource.
+func retryPolicyFor(dsInfo *models.DataSource) retryPolicy {
+	if dsInfo == nil || dsInfo.JsonData == nil {
+		return retryPolicy{enabled: true, maxRetries: defaultMaxRetries, baseDelay: defaultBaseDelay}
+	}
+
+	maxRetries := dsInfo.JsonData.Get("queryRetries").MustInt(defaultMaxRetries)
+	baseDelayMs := dsInfo.JsonData.Get("queryRetryBaseDelayMs").MustInt(int(defaultBaseDelay / time.Millisecond))
+
+	return retryPolicy{
+		enabled:    maxRetries > 0,
+		maxRetries: maxRetries,
+		baseDelay:  time.Duration(baseDelayMs) * time.Millisecond,
+	}
+}
+
+// isIdempotent reports whether every query in the batch is safe to retry.
+// Queries that carry their own HTTP method (e.g. a raw JSON-API body query)
+// are only retried when that method is GET, since retrying a POST could
+// double-execute a side-effecting call upstream.
+func isIdempotent(query *TsdbQuery) bool {
+	for _, q := range query.Queries {
+		if q.Model == nil {
+			continue
+		}
+		method := q.Model.Get("method").MustString("GET")
+		if !strings.EqualFold(method, "GET") {
+			return false
+		}
+	}
+	return true
+}
+
+// isTransientError reports whether err looks like a transient network
+// failure worth retrying: a temporary/timeout net.Error, an EOF reading the
+// response body, or a connection reset by the peer.
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return true
+		}
+		// nolint: staticcheck
+		if netErr.Temporary() {
+			return true
+		}
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// queryWithRetry calls endpoint.Query, retrying up to policy.maxRetries times
+// with exponential backoff when the error is transient. It honors ctx
+// cancellation between attempts.
+func queryWithRetry(ctx context.Context, endpoint TsdbQueryEndpoint, dsInfo *models.DataSource, query *TsdbQuery, policy retryPolicy) (*Response, error) {
+	var resp *Response
+	var err error
+
+	delay := policy.baseDelay
+	for attempt := 0; attempt <= policy.maxRetries; attempt++ {
+		resp, err = endpoint.Query(ctx, dsInfo, query)
+		if err == nil || !isTransientError(err) {
+			return resp, err
+		}
+
+		if attempt == policy.maxRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	return resp, err
+}
@@ -0,0 +1,68 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type stubQueryEndpoint struct {
+	err      error
+	attempts int
+}
+
+func (s *stubQueryEndpoint) Query(ctx context.Context, ds *models.DataSource, query *TsdbQuery) (*Response, error) {
+	s.attempts++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Response{}, nil
+}
+
+func TestQueryWithRetryRetriesTransientErrorUntilMaxRetries(t *testing.T) {
+	endpoint := &stubQueryEndpoint{err: errors.New("read: connection reset by peer")}
+	policy := retryPolicy{enabled: true, maxRetries: 2, baseDelay: time.Millisecond}
+
+	_, err := queryWithRetry(context.Background(), endpoint, nil, &TsdbQuery{}, policy)
+	if err == nil {
+		t.Fatalf("expected the transient error to be surfaced once retries are exhausted")
+	}
+	if endpoint.attempts != policy.maxRetries+1 {
+		t.Fatalf("expected %d attempts, got %d", policy.maxRetries+1, endpoint.attempts)
+	}
+}
+
+func TestQueryWithRetryDoesNotRetryNonTransientError(t *testing.T) {
+	endpoint := &stubQueryEndpoint{err: errors.New("permission denied")}
+	policy := retryPolicy{enabled: true, maxRetries: 2, baseDelay: time.Millisecond}
+
+	_, err := queryWithRetry(context.Background(), endpoint, nil, &TsdbQuery{}, policy)
+	if err == nil {
+		t.Fatalf("expected the non-transient error to be returned")
+	}
+	if endpoint.attempts != 1 {
+		t.Fatalf("expected a single attempt for a non-transient error, got %d", endpoint.attempts)
+	}
+}
+
+func TestQueryWithRetryAbortsOnContextCancellation(t *testing.T) {
+	endpoint := &stubQueryEndpoint{err: errors.New("read: connection reset by peer")}
+	policy := retryPolicy{enabled: true, maxRetries: 5, baseDelay: 50 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := queryWithRetry(ctx, endpoint, nil, &TsdbQuery{}, policy)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if endpoint.attempts >= policy.maxRetries+1 {
+		t.Fatalf("expected cancellation to abort backoff before all retries ran, got %d attempts", endpoint.attempts)
+	}
+}
@@ -0,0 +1,38 @@
+package tsdb
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// HandleRequestStream behaves like HandleRequest, but hands back each RefId's
+// QueryResult on the returned channel as it becomes available instead of a
+// single buffered Response, so a caller streaming the HTTP response can start
+// writing before the whole batch finishes.
+//
+// TsdbQueryEndpoint.Query itself still returns a fully-buffered Response, so
+// today this only lets results for independent RefIds start streaming out as
+// soon as HandleRequest as a whole completes; making an individual large
+// query (e.g. a million-point random_walk) stream point-by-point would
+// require a streaming Query method on the plugin adapter itself.
+func HandleRequestStream(ctx context.Context, dsInfo *models.DataSource, query *TsdbQuery) (<-chan *QueryResult, error) {
+	resp, err := HandleRequest(ctx, dsInfo, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *QueryResult, len(resp.Results))
+	go func() {
+		defer close(ch)
+		for _, res := range resp.Results {
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- res:
+			}
+		}
+	}()
+
+	return ch, nil
+}